@@ -1,13 +1,10 @@
 package gost
 
 import (
-	"bytes"
+	"context"
 	"errors"
-	"fmt"
 	"net"
 	"time"
-
-	"github.com/go-log/log"
 )
 
 var (
@@ -101,10 +98,23 @@ func (c *Chain) IsEmpty() bool {
 // Dial connects to the target address addr through the chain.
 // If the chain is empty, it will use the net.Dial directly.
 func (c *Chain) Dial(addr string, opts ...ChainOption) (conn net.Conn, err error) {
+	return c.DialContext(context.Background(), addr, opts...)
+}
+
+// DialContext connects to the target address addr through the chain.
+// The context governs the whole operation including every retry attempt;
+// once ctx is done, DialContext returns ctx.Err() without starting another
+// attempt. If the chain is empty, it will use net.Dial directly.
+func (c *Chain) DialContext(ctx context.Context, addr string, opts ...ChainOption) (conn net.Conn, err error) {
 	options := &ChainOptions{}
 	for _, opt := range opts {
 		opt(options)
 	}
+	logger := chainLogger(options).With("addr", addr)
+
+	if options.RaceN > 1 {
+		return c.dialRace(ctx, addr, options)
+	}
 
 	retries := 1
 	if c != nil && c.Retries > 0 {
@@ -115,7 +125,16 @@ func (c *Chain) Dial(addr string, opts ...ChainOption) (conn net.Conn, err error
 	}
 
 	for i := 0; i < retries; i++ {
-		conn, err = c.dialWithOptions(addr, options)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		if i > 0 {
+			logger.Log("event", "retry", "attempt", i)
+		}
+		conn, err = c.dialWithOptions(ctx, addr, options)
 		if err == nil {
 			break
 		}
@@ -123,27 +142,51 @@ func (c *Chain) Dial(addr string, opts ...ChainOption) (conn net.Conn, err error
 	return
 }
 
-func (c *Chain) dialWithOptions(addr string, options *ChainOptions) (net.Conn, error) {
+// dialWithOptions connects to addr through the chain itself, so each hop
+// still draws from its group's full NodeIterator: a failed dial/handshake
+// only advances that one group and retries the hop, instead of
+// restarting the whole chain. dialRace instead hands dialRoute a single
+// route pinned by DialIter per concurrent attempt, since it genuinely
+// needs one concrete candidate set per race slot.
+func (c *Chain) dialWithOptions(ctx context.Context, addr string, options *ChainOptions) (net.Conn, error) {
 	if options == nil {
 		options = &ChainOptions{}
 	}
-	route, err := c.selectRouteFor(addr)
-	if err != nil {
-		return nil, err
-	}
 
-	ipAddr := c.resolve(addr, options.Resolver, options.Hosts)
+	addr = sniffAddr(addr, options)
+	logger := chainLogger(options).With("addr", addr)
+	ipAddr := c.resolve(addr, options.Resolver, options.Hosts, logger)
 
+	return c.dialRoute(ctx, c, addr, ipAddr, options, logger)
+}
+
+// dialRoute connects through route - an empty chain, meaning dial addr
+// directly; the real chain c, whose hops each draw from their group's
+// full NodeIterator; or a single route pinned by DialIter - and connects
+// to ipAddr as the final hop. Candidate nodes for each hop are pulled
+// lazily from that group's NodeIterator: a failed dial/handshake only
+// advances the failing group's iterator and retries that hop, instead of
+// discarding the hops already connected and restarting the whole route
+// the way the old per-group Next() selection did.
+func (c *Chain) dialRoute(ctx context.Context, route *Chain, addr, ipAddr string, options *ChainOptions, logger Logger) (net.Conn, error) {
 	if route.IsEmpty() {
-		return net.DialTimeout("tcp", ipAddr, options.Timeout)
+		d := net.Dialer{Timeout: options.Timeout}
+		return d.DialContext(ctx, "tcp", ipAddr)
 	}
 
-	conn, err := route.getConn()
+	conn, lastNode, bypassed, err := route.getConnIter(ctx, addr, logger)
 	if err != nil {
 		return nil, err
 	}
+	if bypassed {
+		d := net.Dialer{Timeout: options.Timeout}
+		return d.DialContext(ctx, "tcp", ipAddr)
+	}
 
-	cc, err := route.LastNode().Client.Connect(conn, ipAddr, AddrConnectOption(addr))
+	start := time.Now()
+	cc, err := lastNode.Client.Connect(conn, ipAddr,
+		AddrConnectOption(addr), ContextConnectOption(ctx))
+	logger.With("node", lastNode.String()).Log("event", "connect", "duration", time.Since(start), "err", err)
 	if err != nil {
 		conn.Close()
 		return nil, err
@@ -151,7 +194,131 @@ func (c *Chain) dialWithOptions(addr string, options *ChainOptions) (net.Conn, e
 	return cc, nil
 }
 
-func (c *Chain) resolve(addr string, resolver Resolver, hosts *Hosts) string {
+// getConnIter walks the chain hop by hop, pulling candidates from each
+// group's NodeIterator. A dial/handshake failure on a hop marks that node
+// dead and retries with the same group's next candidate, keeping the
+// connection already established to the previous hops. It reports the
+// last connected node (the caller needs its Client to Connect to the
+// final target) and whether addr matched a bypass rule along the way.
+func (c *Chain) getConnIter(ctx context.Context, addr string, logger Logger) (conn net.Conn, lastNode Node, bypassed bool, err error) {
+	iters := make([]NodeIterator, len(c.nodeGroups))
+	for i, group := range c.nodeGroups {
+		iters[i] = group.Iter()
+	}
+	defer func() {
+		for _, it := range iters {
+			it.Close()
+		}
+	}()
+
+	var (
+		cn        net.Conn
+		connected bool
+		curSeg    = newRoute() // nodes of the current multiplex segment
+	)
+
+	for hop, it := range iters {
+		for {
+			select {
+			case <-ctx.Done():
+				if cn != nil {
+					cn.Close()
+				}
+				return nil, Node{}, false, ctx.Err()
+			default:
+			}
+
+			node, ok := it.Next()
+			if !ok {
+				if cn != nil {
+					cn.Close()
+				}
+				return nil, Node{}, false, ErrEmptyChain
+			}
+			hopLogger := logger.With("hop", hop, "node", node.String(), "group", node.group.ID)
+
+			if node.Bypass.Contains(addr) {
+				hopLogger.Log("event", "bypass")
+				if cn != nil {
+					cn.Close()
+				}
+				return nil, Node{}, true, nil
+			}
+
+			// A multiplex-capable node is re-dialed fresh, tunnelled
+			// through the segment accumulated so far via
+			// ChainDialOption, matching selectRoute's cutoff; the
+			// first hop is dialed directly, and every other hop just
+			// Connects through the previous one.
+			multiplex := node.Client.Transporter.Multiplex()
+			freshDial := hop == 0 || multiplex
+
+			dialOpts := append(append([]DialOption{}, node.DialOptions...), ContextDialOption(ctx))
+			if freshDial && connected {
+				dialOpts = append(dialOpts, ChainDialOption(curSeg))
+			}
+			hsOpts := append(append([]HandshakeOption{}, node.HandshakeOptions...), ContextHandshakeOption(ctx))
+
+			var (
+				cc  net.Conn
+				err error
+			)
+			start := time.Now()
+			if freshDial {
+				cc, err = node.Client.Dial(node.Addr, dialOpts...)
+			} else {
+				cc, err = lastNode.Client.Connect(cn, node.Addr, ContextConnectOption(ctx))
+			}
+			hopLogger.Log("event", "dial", "duration", time.Since(start), "err", err)
+			if err == nil {
+				hsStart := time.Now()
+				cc, err = node.Client.Handshake(cc, hsOpts...)
+				hopLogger.Log("event", "handshake", "duration", time.Since(hsStart), "err", err)
+			}
+			if err != nil {
+				// A hop that merely lost a race (its context was
+				// cancelled because another candidate won) isn't a
+				// dial/handshake failure and must not blacklist an
+				// otherwise healthy node.
+				if !isCanceled(err) {
+					node.group.MarkDeadNode(node.ID)
+					hopLogger.Log("event", "dead-node")
+				}
+				continue // retry this hop with the group's next candidate
+			}
+			node.group.ResetDeadNode(node.ID)
+
+			if freshDial && connected && cn != nil {
+				// cc was just dialed independently through curSeg's
+				// tunnel rather than layered onto cn, so cn is
+				// superseded - close it or it leaks.
+				cn.Close()
+			}
+
+			cn = cc
+			lastNode = node
+			connected = true
+
+			if multiplex {
+				curSeg.Retries = c.Retries
+				curSeg = newRoute()
+			}
+			curSeg.AddNode(node)
+			break
+		}
+	}
+
+	conn = cn
+	return
+}
+
+// isCanceled reports whether err is (or wraps) context.Canceled, as
+// opposed to an actual dial/handshake failure.
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+func (c *Chain) resolve(addr string, resolver Resolver, hosts *Hosts, logger Logger) string {
 	host, port, err := net.SplitHostPort(addr)
 	if err != nil {
 		return addr
@@ -163,7 +330,7 @@ func (c *Chain) resolve(addr string, resolver Resolver, hosts *Hosts) string {
 	if resolver != nil {
 		ips, err := resolver.Resolve(host)
 		if err != nil {
-			log.Logf("[resolver] %s: %v", host, err)
+			logger.With("host", host).Log("event", "resolve", "err", err)
 		}
 		if len(ips) > 0 {
 			return net.JoinHostPort(ips[0].String(), port)
@@ -174,10 +341,19 @@ func (c *Chain) resolve(addr string, resolver Resolver, hosts *Hosts) string {
 
 // Conn obtains a handshaked connection to the last node of the chain.
 func (c *Chain) Conn(opts ...ChainOption) (conn net.Conn, err error) {
+	return c.ConnContext(context.Background(), opts...)
+}
+
+// ConnContext obtains a handshaked connection to the last node of the chain.
+// The context governs the whole operation including every retry attempt;
+// once ctx is done, ConnContext returns ctx.Err() without starting another
+// attempt.
+func (c *Chain) ConnContext(ctx context.Context, opts ...ChainOption) (conn net.Conn, err error) {
 	options := &ChainOptions{}
 	for _, opt := range opts {
 		opt(options)
 	}
+	logger := chainLogger(options)
 
 	retries := 1
 	if c != nil && c.Retries > 0 {
@@ -188,14 +364,24 @@ func (c *Chain) Conn(opts ...ChainOption) (conn net.Conn, err error) {
 	}
 
 	for i := 0; i < retries; i++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		if i > 0 {
+			logger.Log("event", "retry", "attempt", i)
+		}
+
 		var route *Chain
-		route, err = c.selectRoute()
+		route, err = c.selectRoute(logger)
 		if err != nil {
 			continue
 		}
-		conn, err = route.getConn()
+		conn, err = route.getConnContext(ctx, logger)
 		if err != nil {
-			log.Log(err)
+			logger.Log("event", "conn", "err", err)
 			continue
 		}
 
@@ -207,39 +393,68 @@ func (c *Chain) Conn(opts ...ChainOption) (conn net.Conn, err error) {
 // getConn obtains a connection to the last node of the chain.
 // It does not handshake with the last node.
 func (c *Chain) getConn() (conn net.Conn, err error) {
+	return c.getConnContext(context.Background(), defaultLogger)
+}
+
+// getConnContext obtains a connection to the last node of the chain,
+// aborting the hop-by-hop dial/handshake loop as soon as ctx is done.
+// It does not handshake with the last node.
+func (c *Chain) getConnContext(ctx context.Context, logger Logger) (conn net.Conn, err error) {
 	if c.IsEmpty() {
 		err = ErrEmptyChain
 		return
 	}
 	nodes := c.Nodes()
 	node := nodes[0]
+	hopLogger := logger.With("hop", 0, "node", node.String(), "group", node.group.ID)
 
-	cn, err := node.Client.Dial(node.Addr, node.DialOptions...)
+	start := time.Now()
+	cn, err := node.Client.Dial(node.Addr, append(node.DialOptions, ContextDialOption(ctx))...)
+	hopLogger.Log("event", "dial", "duration", time.Since(start), "err", err)
 	if err != nil {
 		node.group.MarkDeadNode(node.ID)
+		hopLogger.Log("event", "dead-node")
 		return
 	}
 
-	cn, err = node.Client.Handshake(cn, node.HandshakeOptions...)
+	hsStart := time.Now()
+	cn, err = node.Client.Handshake(cn, append(node.HandshakeOptions, ContextHandshakeOption(ctx))...)
+	hopLogger.Log("event", "handshake", "duration", time.Since(hsStart), "err", err)
 	if err != nil {
 		node.group.MarkDeadNode(node.ID)
+		hopLogger.Log("event", "dead-node")
 		return
 	}
 	node.group.ResetDeadNode(node.ID)
 
 	preNode := node
-	for _, node := range nodes[1:] {
+	for hop, node := range nodes[1:] {
+		select {
+		case <-ctx.Done():
+			cn.Close()
+			err = ctx.Err()
+			return
+		default:
+		}
+		hopLogger := logger.With("hop", hop+1, "node", node.String(), "group", node.group.ID)
+
+		connStart := time.Now()
 		var cc net.Conn
-		cc, err = preNode.Client.Connect(cn, node.Addr)
+		cc, err = preNode.Client.Connect(cn, node.Addr, ContextConnectOption(ctx))
+		hopLogger.Log("event", "connect", "duration", time.Since(connStart), "err", err)
 		if err != nil {
 			cn.Close()
 			node.group.MarkDeadNode(node.ID)
+			hopLogger.Log("event", "dead-node")
 			return
 		}
-		cc, err = node.Client.Handshake(cc, node.HandshakeOptions...)
+		hsStart := time.Now()
+		cc, err = node.Client.Handshake(cc, append(node.HandshakeOptions, ContextHandshakeOption(ctx))...)
+		hopLogger.Log("event", "handshake", "duration", time.Since(hsStart), "err", err)
 		if err != nil {
 			cn.Close()
 			node.group.MarkDeadNode(node.ID)
+			hopLogger.Log("event", "dead-node")
 			return
 		}
 		node.group.ResetDeadNode(node.ID)
@@ -252,20 +467,19 @@ func (c *Chain) getConn() (conn net.Conn, err error) {
 	return
 }
 
-func (c *Chain) selectRoute() (route *Chain, err error) {
+func (c *Chain) selectRoute(logger Logger) (route *Chain, err error) {
 	if c.IsEmpty() || c.isRoute {
 		return c, nil
 	}
 
-	buf := bytes.Buffer{}
 	route = newRoute()
 
-	for _, group := range c.nodeGroups {
+	for i, group := range c.nodeGroups {
 		node, err := group.Next()
 		if err != nil {
 			return nil, err
 		}
-		buf.WriteString(fmt.Sprintf("%s -> ", node.String()))
+		logger.Log("event", "select-route", "hop", i, "node", node.String(), "group", group.ID)
 
 		if node.Client.Transporter.Multiplex() {
 			node.DialOptions = append(node.DialOptions,
@@ -278,37 +492,31 @@ func (c *Chain) selectRoute() (route *Chain, err error) {
 	}
 	route.Retries = c.Retries
 
-	if Debug {
-		log.Log("select route:", buf.String())
-	}
 	return
 }
 
 // selectRouteFor selects route with bypass testing.
-func (c *Chain) selectRouteFor(addr string) (route *Chain, err error) {
+func (c *Chain) selectRouteFor(addr string, logger Logger) (route *Chain, err error) {
 	if c.IsEmpty() || c.isRoute {
 		return c, nil
 	}
 
-	buf := bytes.Buffer{}
 	route = newRoute()
 
-	for _, group := range c.nodeGroups {
+	for i, group := range c.nodeGroups {
 		var node Node
 		node, err = group.Next()
 		if err != nil {
 			return
 		}
+		hopLogger := logger.With("hop", i, "node", node.String(), "group", group.ID)
 
 		if node.Bypass.Contains(addr) {
-			if Debug {
-				buf.WriteString(fmt.Sprintf("[bypass]%s -> %s", node.String(), addr))
-				log.Log("[route]", buf.String())
-			}
+			hopLogger.Log("event", "bypass", "addr", addr)
 			return
 		}
 
-		buf.WriteString(fmt.Sprintf("%s -> ", node.String()))
+		hopLogger.Log("event", "select-route", "addr", addr)
 
 		if node.Client.Transporter.Multiplex() {
 			node.DialOptions = append(node.DialOptions,
@@ -321,19 +529,20 @@ func (c *Chain) selectRouteFor(addr string) (route *Chain, err error) {
 	}
 	route.Retries = c.Retries
 
-	if Debug {
-		buf.WriteString(addr)
-		log.Log("[route]", buf.String())
-	}
 	return
 }
 
 // ChainOptions holds options for Chain.
 type ChainOptions struct {
-	Retries  int
-	Timeout  time.Duration
-	Hosts    *Hosts
-	Resolver Resolver
+	Retries     int
+	Timeout     time.Duration
+	Hosts       *Hosts
+	Resolver    Resolver
+	SniffPeek   []byte
+	Sniffers    []Sniffer
+	RaceN       int
+	RaceStagger time.Duration
+	Logger      Logger
 }
 
 // ChainOption allows a common way to set chain options.
@@ -0,0 +1,29 @@
+package gost
+
+import "context"
+
+// ContextDialOption sets the context used by Client.Dial for cancellation
+// and deadline propagation. It mirrors AddrConnectOption/etc. in that it is
+// just another DialOption, so it composes with whatever per-node dial
+// options are already configured.
+func ContextDialOption(ctx context.Context) DialOption {
+	return func(opts *DialOptions) {
+		opts.Context = ctx
+	}
+}
+
+// ContextHandshakeOption sets the context used by Client.Handshake for
+// cancellation and deadline propagation.
+func ContextHandshakeOption(ctx context.Context) HandshakeOption {
+	return func(opts *HandshakeOptions) {
+		opts.Context = ctx
+	}
+}
+
+// ContextConnectOption sets the context used by Client.Connect for
+// cancellation and deadline propagation.
+func ContextConnectOption(ctx context.Context) ConnectOption {
+	return func(opts *ConnectOptions) {
+		opts.Context = ctx
+	}
+}
@@ -0,0 +1,310 @@
+package gost
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	healthCheckDefaultWorkers         = 8
+	healthCheckMinBackoff             = 5 * time.Second
+	healthCheckMaxBackoff             = 5 * time.Minute
+	healthCheckDefaultTimeoutFraction = 2
+)
+
+// NodeStatus reports the result of the most recent health probe for a
+// node.
+type NodeStatus struct {
+	Alive   bool
+	Latency time.Duration
+	Time    time.Time
+	Error   error
+}
+
+// HealthCheckOptions holds options for a HealthChecker.
+type HealthCheckOptions struct {
+	Workers      int
+	ProbeTimeout time.Duration
+}
+
+// HealthCheckOption allows a common way to set health check options.
+type HealthCheckOption func(opts *HealthCheckOptions)
+
+// WorkersHealthCheckOption sets the size of the bounded probe worker
+// pool, so a single round of probing cannot stampede a large group.
+func WorkersHealthCheckOption(n int) HealthCheckOption {
+	return func(opts *HealthCheckOptions) {
+		opts.Workers = n
+	}
+}
+
+// ProbeTimeoutHealthCheckOption bounds a single node's Dial+Handshake
+// probe, so one unreachable node can't hang a worker indefinitely and
+// shrink the pool round after round. Without one, it defaults to
+// interval/healthCheckDefaultTimeoutFraction, given to NewHealthChecker.
+func ProbeTimeoutHealthCheckOption(d time.Duration) HealthCheckOption {
+	return func(opts *HealthCheckOptions) {
+		opts.ProbeTimeout = d
+	}
+}
+
+type nodeHealthState struct {
+	backoff   time.Duration
+	nextProbe time.Time
+}
+
+// HealthChecker periodically probes the nodes of one or more NodeGroups
+// by dialing and handshaking with them, and drives MarkDeadNode /
+// ResetDeadNode from the result. This catches a flaky node proactively,
+// instead of waiting for the selector to hand it to a caller and watch
+// the dial fail. Dead nodes are re-probed on an exponential backoff with
+// jitter, capped at healthCheckMaxBackoff.
+type HealthChecker struct {
+	interval     time.Duration
+	workers      int
+	probeTimeout time.Duration
+
+	mu     sync.Mutex
+	groups []*NodeGroup
+	state  map[string]*nodeHealthState
+	status map[string]*NodeStatus
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewHealthChecker creates a HealthChecker that probes watched groups
+// every interval.
+func NewHealthChecker(interval time.Duration, opts ...HealthCheckOption) *HealthChecker {
+	options := HealthCheckOptions{Workers: healthCheckDefaultWorkers}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Workers <= 0 {
+		options.Workers = healthCheckDefaultWorkers
+	}
+	if options.ProbeTimeout <= 0 {
+		options.ProbeTimeout = interval / healthCheckDefaultTimeoutFraction
+	}
+
+	return &HealthChecker{
+		interval:     interval,
+		workers:      options.Workers,
+		probeTimeout: options.ProbeTimeout,
+		state:        make(map[string]*nodeHealthState),
+		status:       make(map[string]*NodeStatus),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Watch adds group(s) to the set this checker probes.
+func (hc *HealthChecker) Watch(groups ...*NodeGroup) {
+	if hc == nil {
+		return
+	}
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.groups = append(hc.groups, groups...)
+}
+
+// Status returns the last known probe result for nodeID, if any probe
+// has completed for it yet. Selectors can use Latency to weight an
+// otherwise-tied choice.
+func (hc *HealthChecker) Status(nodeID string) (NodeStatus, bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	st, ok := hc.status[nodeID]
+	if !ok {
+		return NodeStatus{}, false
+	}
+	return *st, true
+}
+
+// Run starts the periodic probing loop and blocks until Stop is called.
+// Callers typically invoke it with `go hc.Run()`.
+func (hc *HealthChecker) Run() {
+	defer close(hc.done)
+
+	ticker := time.NewTicker(hc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hc.stop:
+			return
+		case <-ticker.C:
+			hc.probeRound()
+		}
+	}
+}
+
+// Stop cancels the checker and waits for any in-flight probing round to
+// finish, so the subsystem shuts down cleanly alongside its chain.
+func (hc *HealthChecker) Stop() {
+	select {
+	case <-hc.stop:
+	default:
+		close(hc.stop)
+	}
+	<-hc.done
+}
+
+// probeRound probes every node that is due, using a bounded worker pool
+// so a large group can't be stampeded in one round. Candidates are drawn
+// through a FairMix over each watched group's NodeIterator, so a round
+// spanning a huge group and a tiny one still interleaves both fairly
+// instead of exhausting the huge group's nodes before the tiny group is
+// ever visited.
+func (hc *HealthChecker) probeRound() {
+	hc.mu.Lock()
+	groups := append([]*NodeGroup{}, hc.groups...)
+	hc.mu.Unlock()
+
+	iters := make([]NodeIterator, len(groups))
+	for i, group := range groups {
+		iters[i] = group.Iter()
+	}
+	mix := NewFairMix(iters...)
+	defer mix.Close()
+
+	now := time.Now()
+	var due []Node
+	for {
+		node, ok := mix.Next()
+		if !ok {
+			break
+		}
+		if hc.dueForProbe(node.ID, now) {
+			due = append(due, node)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	workers := hc.workers
+	if workers > len(due) {
+		workers = len(due)
+	}
+
+	jobs := make(chan Node)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for node := range jobs {
+				hc.probe(node)
+			}
+		}()
+	}
+
+feed:
+	for _, node := range due {
+		select {
+		case jobs <- node:
+		case <-hc.stop:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (hc *HealthChecker) dueForProbe(nodeID string, now time.Time) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	st := hc.state[nodeID]
+	return st == nil || !now.Before(st.nextProbe)
+}
+
+// probe dials and handshakes with node using its own configured Client,
+// records the outcome, and drives the group's dead-node state. Both calls
+// are bounded by hc.probeTimeout so a single unreachable node can't hang
+// a worker indefinitely and block Stop from ever returning.
+func (hc *HealthChecker) probe(node Node) {
+	ctx, cancel := context.WithTimeout(context.Background(), hc.probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	cn, err := node.Client.Dial(node.Addr, ContextDialOption(ctx))
+	if err == nil {
+		cn, err = node.Client.Handshake(cn, ContextHandshakeOption(ctx))
+	}
+	if cn != nil {
+		cn.Close()
+	}
+	latency := time.Since(start)
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	hc.status[node.ID] = &NodeStatus{
+		Alive:   err == nil,
+		Latency: latency,
+		Time:    start,
+		Error:   err,
+	}
+
+	st := hc.state[node.ID]
+	if st == nil {
+		st = &nodeHealthState{}
+		hc.state[node.ID] = st
+	}
+	if err == nil {
+		st.backoff = 0
+		st.nextProbe = time.Time{} // due again at the next round
+		node.group.ResetDeadNode(node.ID)
+		return
+	}
+	st.backoff = growBackoff(st.backoff)
+	st.nextProbe = start.Add(jitter(st.backoff))
+	node.group.MarkDeadNode(node.ID)
+}
+
+func growBackoff(cur time.Duration) time.Duration {
+	if cur <= 0 {
+		return healthCheckMinBackoff
+	}
+	cur *= 2
+	if cur > healthCheckMaxBackoff {
+		cur = healthCheckMaxBackoff
+	}
+	return cur
+}
+
+// jitter returns a duration in [d/2, d] so probes don't all realign to
+// the same wall-clock tick after a shared outage.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// SetHealthCheck registers the group with hc so its nodes are probed in
+// the background.
+func (g *NodeGroup) SetHealthCheck(hc *HealthChecker) {
+	if g == nil || hc == nil {
+		return
+	}
+	hc.Watch(g)
+}
+
+// StartHealthCheck starts a background HealthChecker that probes every
+// node group in the chain at the given interval, marking/clearing dead
+// nodes as probes fail or recover. The checker stops automatically once
+// its Stop method is called; callers should do so when the chain is torn
+// down.
+func (c *Chain) StartHealthCheck(interval time.Duration, opts ...HealthCheckOption) *HealthChecker {
+	hc := NewHealthChecker(interval, opts...)
+	if c != nil {
+		hc.Watch(c.nodeGroups...)
+	}
+	go hc.Run()
+	return hc
+}
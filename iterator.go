@@ -0,0 +1,302 @@
+package gost
+
+import (
+	"sync"
+	"time"
+)
+
+// NodeIterator iterates over the candidate nodes of a NodeGroup, producing
+// one at a time. Unlike NodeGroup.Next, which commits to a single pick per
+// call, a NodeIterator lets a caller walk past a bad choice without giving
+// up on the group entirely.
+type NodeIterator interface {
+	// Next returns the next candidate node, or false once the iterator is
+	// exhausted.
+	Next() (Node, bool)
+	// Close releases any resources held by the iterator.
+	Close()
+}
+
+// nodeGroupIterator is the default NodeIterator for a NodeGroup: it pulls
+// from the group's existing selector (strategy + dead-node state) one
+// candidate at a time, up to the number of nodes the group holds.
+type nodeGroupIterator struct {
+	group  *NodeGroup
+	remain int
+}
+
+// Iter returns a NodeIterator over the group's nodes, selected according
+// to the group's configured strategy and current dead-node state.
+func (g *NodeGroup) Iter() NodeIterator {
+	return &nodeGroupIterator{
+		group:  g,
+		remain: len(g.Nodes()),
+	}
+}
+
+func (it *nodeGroupIterator) Next() (Node, bool) {
+	if it == nil || it.group == nil || it.remain <= 0 {
+		return Node{}, false
+	}
+	node, err := it.group.Next()
+	if err != nil {
+		it.remain = 0
+		return Node{}, false
+	}
+	it.remain--
+	return node, true
+}
+
+func (it *nodeGroupIterator) Close() {
+	if it != nil {
+		it.remain = 0
+	}
+}
+
+const (
+	minMixTimeout = 10 * time.Millisecond
+	maxMixTimeout = 2 * time.Second
+)
+
+// mixSource runs one underlying iterator on its own goroutine, serialized
+// so at most one call into it is ever in flight: the goroutine blocks
+// trying to hand off each node until pull receives it or the source is
+// closed, so a pull that times out and moves on can never race a later
+// pull against the same still-in-flight Next() call.
+type mixSource struct {
+	ch      chan Node
+	quit    chan struct{}
+	timeout time.Duration
+}
+
+func newMixSource(it NodeIterator) *mixSource {
+	src := &mixSource{ch: make(chan Node), quit: make(chan struct{}), timeout: minMixTimeout}
+	go src.run(it)
+	return src
+}
+
+func (src *mixSource) run(it NodeIterator) {
+	defer close(src.ch)
+	for {
+		node, ok := it.Next()
+		if !ok {
+			return
+		}
+		select {
+		case src.ch <- node:
+		case <-src.quit:
+			return
+		}
+	}
+}
+
+func (src *mixSource) close() {
+	select {
+	case <-src.quit:
+	default:
+		close(src.quit)
+	}
+}
+
+// FairMix combines several NodeIterators into one, visiting sources
+// round-robin so no single source dominates the mix - e.g. a large
+// NodeGroup can't crowd out a small one. Each source carries its own pull
+// timeout that grows when it is slow to produce a node and shrinks back
+// down as soon as it does, so one slow source can fall behind without
+// stalling the others.
+type FairMix struct {
+	mu      sync.Mutex
+	sources []*mixSource
+	next    int
+}
+
+// NewFairMix creates a FairMix over the given iterators.
+func NewFairMix(its ...NodeIterator) *FairMix {
+	mx := &FairMix{}
+	for _, it := range its {
+		if it == nil {
+			continue
+		}
+		mx.sources = append(mx.sources, newMixSource(it))
+	}
+	return mx
+}
+
+// Next returns the next node produced by any live source. It visits
+// sources round-robin, giving each one up to its current timeout to
+// produce before moving on to the next. A source that is exhausted (its
+// underlying iterator ran out) is dropped from the mix. Next also returns
+// false, with the remaining sources still live, once every source has
+// timed out three times in a row without any source running out.
+func (mx *FairMix) Next() (Node, bool) {
+	if mx == nil {
+		return Node{}, false
+	}
+
+	mx.mu.Lock()
+	defer mx.mu.Unlock()
+
+	noProgress := 0
+	for len(mx.sources) > 0 && noProgress < 3*len(mx.sources) {
+		i := mx.next % len(mx.sources)
+		src := mx.sources[i]
+		mx.next++
+
+		node, ok, timedOut := mx.pull(src)
+		if ok {
+			return node, true
+		}
+		if timedOut {
+			noProgress++
+			continue
+		}
+
+		src.close()
+		mx.sources = append(mx.sources[:i], mx.sources[i+1:]...)
+		if mx.next > 0 {
+			mx.next--
+		}
+		noProgress = 0
+	}
+	return Node{}, false
+}
+
+// pull waits for src's next node, bounding the wait by src's current
+// timeout. The timeout shrinks on success and grows on timeout, within
+// [minMixTimeout, maxMixTimeout].
+func (mx *FairMix) pull(src *mixSource) (node Node, ok bool, timedOut bool) {
+	select {
+	case node, ok := <-src.ch:
+		if ok {
+			src.timeout /= 2
+			if src.timeout < minMixTimeout {
+				src.timeout = minMixTimeout
+			}
+		}
+		return node, ok, false
+	case <-time.After(src.timeout):
+		src.timeout *= 2
+		if src.timeout > maxMixTimeout {
+			src.timeout = maxMixTimeout
+		}
+		return Node{}, false, true
+	}
+}
+
+// Close releases every underlying source.
+func (mx *FairMix) Close() {
+	if mx == nil {
+		return
+	}
+	mx.mu.Lock()
+	defer mx.mu.Unlock()
+	for _, src := range mx.sources {
+		src.close()
+	}
+	mx.sources = nil
+}
+
+// RouteIterator enumerates candidate routes through a Chain, advancing
+// hop by hop instead of committing to one path per call. It is meant for
+// callers such as crawlers or health checkers that want to walk every
+// viable path lazily rather than have the whole table handed to them at
+// once.
+type RouteIterator interface {
+	// Next returns the next candidate route, or false once every
+	// combination of live nodes has been exhausted.
+	Next() (*Chain, bool)
+	Close()
+}
+
+// chainRouteIterator produces every combination of the chain's groups'
+// candidate nodes (their cartesian product) by walking an odometer over
+// each group's full candidate list, so a 2-node group chained with a
+// 3-node group yields all 6 viable paths rather than just 2.
+type chainRouteIterator struct {
+	chain *Chain
+	lists [][]Node
+	idx   []int
+	done  bool
+}
+
+// DialIter returns a RouteIterator over every candidate route to addr.
+// Unlike Dial, which commits to a route and retries, DialIter lets the
+// caller enumerate every viable path itself.
+func (c *Chain) DialIter(addr string) RouteIterator {
+	if c.IsEmpty() || c.isRoute {
+		return &singleRouteIterator{route: c}
+	}
+
+	it := &chainRouteIterator{chain: c}
+	for _, group := range c.nodeGroups {
+		gi := group.Iter()
+		var nodes []Node
+		for {
+			node, ok := gi.Next()
+			if !ok {
+				break
+			}
+			nodes = append(nodes, node)
+		}
+		gi.Close()
+
+		if len(nodes) == 0 {
+			return &chainRouteIterator{done: true}
+		}
+		it.lists = append(it.lists, nodes)
+	}
+	it.idx = make([]int, len(it.lists))
+	return it
+}
+
+func (it *chainRouteIterator) Next() (*Chain, bool) {
+	if it == nil || it.done || len(it.lists) == 0 {
+		return nil, false
+	}
+
+	route := newRoute()
+	for i, nodes := range it.lists {
+		node := nodes[it.idx[i]]
+		if node.Client.Transporter.Multiplex() {
+			node.DialOptions = append(node.DialOptions, ChainDialOption(route))
+			route = newRoute()
+		}
+		route.AddNode(node)
+	}
+	route.Retries = it.chain.Retries
+
+	it.advance()
+	return route, true
+}
+
+// advance increments the odometer over it.lists' indices, marking the
+// walk done once every combination has been produced.
+func (it *chainRouteIterator) advance() {
+	for i := len(it.idx) - 1; i >= 0; i-- {
+		it.idx[i]++
+		if it.idx[i] < len(it.lists[i]) {
+			return
+		}
+		it.idx[i] = 0
+	}
+	it.done = true
+}
+
+func (it *chainRouteIterator) Close() {}
+
+// singleRouteIterator wraps an already-resolved route (an empty chain or
+// one already cut down to a single path) as a one-shot RouteIterator.
+type singleRouteIterator struct {
+	route *Chain
+	done  bool
+}
+
+func (it *singleRouteIterator) Next() (*Chain, bool) {
+	if it == nil || it.done {
+		return nil, false
+	}
+	it.done = true
+	return it.route, true
+}
+
+func (it *singleRouteIterator) Close() {}
@@ -0,0 +1,74 @@
+package gost
+
+import (
+	"fmt"
+	"strings"
+
+	golog "github.com/go-log/log"
+)
+
+// Logger is a structured logging sink for chain routing decisions. It
+// replaces the ad-hoc log.Log("[route]", buf.String()) / log.Logf calls
+// that used to build pre-formatted strings, emitting key/value pairs
+// instead so upstream apps can capture route decisions in their own
+// logging pipeline.
+type Logger interface {
+	// With returns a Logger that prefixes every subsequent Log call with
+	// the given key/value pairs, in addition to any it already carries.
+	With(kv ...interface{}) Logger
+	// Log emits one structured event, e.g. Log("event", "dial", "err", err).
+	Log(kv ...interface{})
+}
+
+// LoggerChainOption sets the Logger a Chain uses to record routing
+// decisions for a single Dial/Conn call. Without one, chains fall back
+// to the default logger, which degrades to the github.com/go-log/log
+// sink for backward compatibility.
+func LoggerChainOption(logger Logger) ChainOption {
+	return func(opts *ChainOptions) {
+		opts.Logger = logger
+	}
+}
+
+// chainLogger returns the Logger configured on options, or the default
+// one if none was set.
+func chainLogger(options *ChainOptions) Logger {
+	if options != nil && options.Logger != nil {
+		return options.Logger
+	}
+	return defaultLogger
+}
+
+// defaultLogger adapts Logger to the legacy github.com/go-log/log sink
+// and only emits when Debug is enabled. This matches the old [route]
+// messages; the resolver and Conn error messages, which were logged
+// regardless of Debug, are now also only emitted when Debug is enabled.
+var defaultLogger Logger = gologLogger{}
+
+type gologLogger struct {
+	kv []interface{}
+}
+
+func (l gologLogger) With(kv ...interface{}) Logger {
+	return gologLogger{kv: append(append([]interface{}{}, l.kv...), kv...)}
+}
+
+func (l gologLogger) Log(kv ...interface{}) {
+	if !Debug {
+		return
+	}
+	golog.Log(formatKV(append(append([]interface{}{}, l.kv...), kv...)))
+}
+
+// formatKV renders key/value pairs as the "key=value key=value" text the
+// previous ad-hoc [route]/[resolver] strings approximated by hand.
+func formatKV(kv []interface{}) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%v=%v", kv[i], kv[i+1])
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package gost
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// defaultRaceStagger is the delay between successive candidates launched
+// by RaceChainOption when no stagger is given.
+const defaultRaceStagger = 250 * time.Millisecond
+
+// RaceChainOption makes Chain.Dial launch up to n concurrent dial
+// attempts against distinct candidate routes instead of the usual serial
+// retry loop, staggered by stagger apart (happy-eyeballs style). The
+// first attempt to complete Client.Connect wins; the rest are cancelled
+// and their partial connections closed. This cuts tail latency when a
+// NodeGroup mixes fast and slow upstreams. A stagger of 0 uses
+// defaultRaceStagger.
+func RaceChainOption(n int, stagger time.Duration) ChainOption {
+	return func(opts *ChainOptions) {
+		opts.RaceN = n
+		opts.RaceStagger = stagger
+	}
+}
+
+type raceResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialRace runs up to options.RaceN concurrent dial attempts, staggered
+// by options.RaceStagger, and returns the first to succeed. Each attempt
+// is pinned to its own candidate route pulled from DialIter, so slots
+// race genuinely distinct routes instead of every attempt re-deriving
+// the same candidates from scratch. Dead-node marking happens inside
+// dialRoute/getConnIter as usual, so a loser whose dial actually failed
+// is still marked dead; a loser that was merely cancelled is left
+// untouched.
+func (c *Chain) dialRace(ctx context.Context, addr string, options *ChainOptions) (net.Conn, error) {
+	n := options.RaceN
+	if n < 1 {
+		n = 1
+	}
+	stagger := options.RaceStagger
+	if stagger <= 0 {
+		stagger = defaultRaceStagger
+	}
+
+	addr = sniffAddr(addr, options)
+	logger := chainLogger(options).With("addr", addr)
+	ipAddr := c.resolve(addr, options.Resolver, options.Hosts, logger)
+
+	routeIter := c.DialIter(addr)
+	defer routeIter.Close()
+
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan raceResult, n)
+	launched := 0
+
+	launch := func() bool {
+		route, ok := routeIter.Next()
+		if !ok {
+			return false
+		}
+		launched++
+		go func() {
+			conn, err := c.dialRoute(raceCtx, route, addr, ipAddr, options, logger)
+			results <- raceResult{conn, err}
+		}()
+		return true
+	}
+
+	if !launch() {
+		return nil, ErrEmptyChain
+	}
+
+	ticker := time.NewTicker(stagger)
+	defer ticker.Stop()
+
+	var firstErr error
+	pending := 1
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				cancel()
+				go drainRace(results, pending)
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+		case <-ticker.C:
+			if launched < n {
+				if launch() {
+					pending++
+				}
+			}
+		case <-ctx.Done():
+			cancel()
+			go drainRace(results, pending)
+			return nil, ctx.Err()
+		}
+	}
+	return nil, firstErr
+}
+
+// drainRace waits for the remaining n in-flight attempts after the race
+// has already been decided, closing any connection a loser still manages
+// to establish.
+func drainRace(results chan raceResult, n int) {
+	for i := 0; i < n; i++ {
+		res := <-results
+		if res.conn != nil {
+			res.conn.Close()
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package gost
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ErrNotSniffed indicates a Sniffer did not recognize the peeked bytes
+// as its protocol.
+var ErrNotSniffed = errors.New("sniff: protocol not recognized")
+
+// Sniffer inspects the first bytes a client sent on a freshly accepted
+// connection and extracts the real destination it asked for. This lets
+// routing and bypass rules operate on domain names even when a
+// transparent-proxy front-end only saw a numeric addr on accept.
+type Sniffer interface {
+	// Sniff extracts the destination host and protocol name from peek,
+	// the raw bytes read from the client so far. It returns ErrNotSniffed
+	// if peek does not look like this sniffer's protocol.
+	Sniff(peek []byte) (host string, proto string, err error)
+}
+
+// SniffChainOption supplies a peeked buffer and the Sniffers to try
+// against it, so Chain.Dial can recover the real destination host and
+// use it in place of the numeric addr before the route's per-hop bypass
+// check runs. Callers that only have a net.Conn to peek from, rather
+// than an already-read buffer, should use PeekSniff instead.
+func SniffChainOption(peek []byte, sniffers ...Sniffer) ChainOption {
+	return func(opts *ChainOptions) {
+		opts.SniffPeek = peek
+		opts.Sniffers = sniffers
+	}
+}
+
+// PeekSniff reads up to n bytes from conn without consuming them from the
+// caller's point of view, and returns a SniffChainOption built from what
+// it read along with a net.Conn that replays those bytes before
+// continuing to read from conn. This lets a caller that only has an
+// accepted net.Conn - not a buffer it peeked itself - still recover the
+// real destination host via sniffers.
+func PeekSniff(conn net.Conn, n int, sniffers ...Sniffer) (net.Conn, ChainOption, error) {
+	buf := make([]byte, n)
+	nr, err := io.ReadFull(conn, buf)
+	peek := buf[:nr]
+	pc := &peekedConn{Conn: conn, r: io.MultiReader(bytes.NewReader(peek), conn)}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return pc, SniffChainOption(nil, sniffers...), err
+	}
+	return pc, SniffChainOption(peek, sniffers...), nil
+}
+
+// peekedConn wraps a net.Conn whose first bytes have already been read
+// elsewhere, replaying them before further reads fall through to the
+// underlying connection.
+type peekedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+// Read implements net.Conn.
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// sniffAddr replaces addr's host with the one recovered by trying each
+// of options' Sniffers against the peeked bytes, stopping at the first
+// one that recognizes them. It returns addr unchanged if nothing was
+// peeked or no sniffer matched.
+func sniffAddr(addr string, options *ChainOptions) string {
+	if options == nil || len(options.SniffPeek) == 0 {
+		return addr
+	}
+
+	for _, sniffer := range options.Sniffers {
+		host, _, err := sniffer.Sniff(options.SniffPeek)
+		if err != nil || host == "" {
+			continue
+		}
+		if _, port, perr := net.SplitHostPort(addr); perr == nil {
+			return net.JoinHostPort(host, port)
+		}
+		return host
+	}
+	return addr
+}
+
+// HTTPHostSniffer recovers the target host from the Host header of an
+// HTTP/1.x request.
+type HTTPHostSniffer struct{}
+
+// Sniff implements the Sniffer interface.
+func (HTTPHostSniffer) Sniff(peek []byte) (host string, proto string, err error) {
+	req, rerr := http.ReadRequest(bufio.NewReader(bytes.NewReader(peek)))
+	if rerr != nil || req.Host == "" {
+		return "", "", ErrNotSniffed
+	}
+	host = req.Host
+	if h, _, serr := net.SplitHostPort(host); serr == nil {
+		host = h
+	}
+	return host, "http", nil
+}
+
+// TLSClientHelloSniffer recovers the SNI server name from a TLS
+// ClientHello record.
+type TLSClientHelloSniffer struct{}
+
+// Sniff implements the Sniffer interface.
+func (TLSClientHelloSniffer) Sniff(peek []byte) (host string, proto string, err error) {
+	name, ok := clientHelloServerName(peek)
+	if !ok {
+		return "", "", ErrNotSniffed
+	}
+	return name, "tls", nil
+}
+
+// clientHelloServerName extracts the server_name extension from a raw
+// TLS handshake record containing a ClientHello. It only walks enough of
+// the structure to reach the extension list, without validating the
+// handshake as a whole.
+func clientHelloServerName(data []byte) (string, bool) {
+	if len(data) < 5 || data[0] != 0x16 { // handshake record
+		return "", false
+	}
+	recLen := int(data[3])<<8 | int(data[4])
+	if len(data) < 5+recLen {
+		return "", false
+	}
+	data = data[5 : 5+recLen]
+
+	if len(data) < 4 || data[0] != 0x01 { // client_hello
+		return "", false
+	}
+	data = data[4:]
+
+	if len(data) < 2+32 {
+		return "", false
+	}
+	data = data[2+32:] // client_version + random
+
+	if len(data) < 1 {
+		return "", false
+	}
+	sessionLen := int(data[0])
+	data = data[1:]
+	if len(data) < sessionLen {
+		return "", false
+	}
+	data = data[sessionLen:]
+
+	if len(data) < 2 {
+		return "", false
+	}
+	cipherLen := int(data[0])<<8 | int(data[1])
+	data = data[2:]
+	if len(data) < cipherLen {
+		return "", false
+	}
+	data = data[cipherLen:]
+
+	if len(data) < 1 {
+		return "", false
+	}
+	compLen := int(data[0])
+	data = data[1:]
+	if len(data) < compLen {
+		return "", false
+	}
+	data = data[compLen:]
+
+	if len(data) < 2 {
+		return "", false
+	}
+	extLen := int(data[0])<<8 | int(data[1])
+	data = data[2:]
+	if len(data) < extLen {
+		return "", false
+	}
+	data = data[:extLen]
+
+	const extServerName = 0x00
+	for len(data) >= 4 {
+		extType := int(data[0])<<8 | int(data[1])
+		l := int(data[2])<<8 | int(data[3])
+		data = data[4:]
+		if len(data) < l {
+			return "", false
+		}
+		if extType == extServerName {
+			name, ok := parseServerNameExt(data[:l])
+			if ok {
+				return name, true
+			}
+		}
+		data = data[l:]
+	}
+	return "", false
+}
+
+// parseServerNameExt parses the body of a server_name extension and
+// returns the first host_name entry in its list.
+func parseServerNameExt(ext []byte) (string, bool) {
+	if len(ext) < 2 {
+		return "", false
+	}
+	listLen := int(ext[0])<<8 | int(ext[1])
+	ext = ext[2:]
+	if len(ext) < listLen {
+		return "", false
+	}
+
+	const nameTypeHostName = 0
+	for len(ext) >= 3 {
+		nameType := ext[0]
+		nameLen := int(ext[1])<<8 | int(ext[2])
+		ext = ext[3:]
+		if len(ext) < nameLen {
+			return "", false
+		}
+		if nameType == nameTypeHostName {
+			return string(ext[:nameLen]), true
+		}
+		ext = ext[nameLen:]
+	}
+	return "", false
+}